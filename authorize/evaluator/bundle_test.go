@@ -0,0 +1,122 @@
+package evaluator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/policy/bundle"
+)
+
+// fakeSource serves a single fixed bundle, signed with key, to a bundle.Loader under test.
+type fakeSource struct {
+	data []byte
+	key  ed25519.PrivateKey
+}
+
+func newFakeSource(t *testing.T, modules map[string]string, data map[string]interface{}, revision string) *fakeSource {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	writeEntry := func(name string, bs []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(bs)), Mode: 0o600}); err != nil {
+			t.Fatalf("writing tar header for %s: %v", name, err)
+		}
+		if _, err := tw.Write(bs); err != nil {
+			t.Fatalf("writing tar entry for %s: %v", name, err)
+		}
+	}
+
+	manifest, err := json.Marshal(bundle.Manifest{Revision: revision})
+	if err != nil {
+		t.Fatalf("marshaling manifest: %v", err)
+	}
+	writeEntry(".manifest", manifest)
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshaling data.json: %v", err)
+	}
+	writeEntry("data.json", dataJSON)
+
+	for name, src := range modules {
+		writeEntry(name, []byte(src))
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("closing gzip writer: %v", err)
+	}
+
+	return &fakeSource{data: buf.Bytes(), key: priv}
+}
+
+func (s *fakeSource) Fetch(context.Context) ([]byte, []byte, error) {
+	return s.data, ed25519.Sign(s.key, s.data), nil
+}
+
+func (s *fakeSource) verifier() bundle.Ed25519Verifier {
+	return bundle.Ed25519Verifier{PublicKey: s.key.Public().(ed25519.PublicKey)}
+}
+
+// TestPolicyEvaluatorEvaluate_BundleDataReachesStore confirms a bundle's data.json (e.g. a
+// centrally-distributed rbac roles/bindings tree) is loaded into the evaluator's Store, not just
+// its .rego modules, by evaluating an rbac rule that only holds once that data is present.
+func TestPolicyEvaluatorEvaluate_BundleDataReachesStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := newFakeSource(t, map[string]string{
+		"policy.rego": `package pomerium.policy
+
+default allow = false
+
+allow {
+	data.pomerium.rbac.bindings[_].subject == input.session.user
+}
+`,
+	}, map[string]interface{}{
+		"pomerium": map[string]interface{}{
+			"rbac": map[string]interface{}{
+				"bindings": []map[string]interface{}{{"role": "admin", "subject": "user-1"}},
+			},
+		},
+	}, "rev-1")
+
+	loader := bundle.NewLoader(src, src.verifier(), time.Hour)
+	go loader.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for loader.Status().Revision == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for bundle to activate")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	store := NewStore()
+	e := &PolicyEvaluator{store: store, bundleLoader: loader}
+
+	output, err := e.Evaluate(ctx, &PolicyInput{Session: RequestSession{User: "user-1"}})
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if !output.Allow {
+		t.Errorf("expected Allow=true once the bundle's rbac data document is loaded into the store, got false")
+	}
+}
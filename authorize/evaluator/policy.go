@@ -2,14 +2,19 @@ package evaluator
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"sync"
 
 	"github.com/open-policy-agent/opa/rego"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/pkg/policy"
+	"github.com/pomerium/pomerium/pkg/policy/bundle"
+	"github.com/pomerium/pomerium/pkg/policy/parser"
 )
 
 // PolicyInput is the input to policy evaluation.
@@ -21,37 +26,182 @@ type PolicyInput struct {
 
 // PolicyOutput is the result of evaluating a policy.
 type PolicyOutput struct {
-	Allow bool
-	Deny  *Denial
+	Allow   bool
+	Denials []Denial
+	// AuditResults records the decisions of rules scoped to EnforcementActionDryRun or
+	// EnforcementActionAudit. These never affect Allow or Denials; they exist so operators can
+	// observe what a new rule would have done before flipping it to EnforcementActionEnforce.
+	AuditResults []AuditResult
+	// Obligations are side-effects the proxy must apply when Allow is true.
+	Obligations []Obligation
+	// Headers are request headers the proxy should set when Allow is true.
+	Headers map[string]string
+	// ClaimsToForward are the JWT claims the proxy should forward when Allow is true.
+	ClaimsToForward map[string]interface{}
+	// TraceID, if set, identifies the evaluation for correlation with other telemetry.
+	TraceID string
+}
+
+// An AuditResult is the decision a dryrun- or audit-scoped rule made, kept separate from the
+// request-time decision.
+type AuditResult struct {
+	Action  parser.EnforcementAction
+	Allow   bool
+	Denials []Denial
 }
 
 // Merge merges another PolicyOutput into this Output. Access is allowed if either is allowed. Access is denied if
 // either is denied. (and denials take precedence)
 func (output *PolicyOutput) Merge(other *PolicyOutput) *PolicyOutput {
 	merged := &PolicyOutput{
-		Allow: output.Allow || other.Allow,
-		Deny:  output.Deny,
+		Allow:           output.Allow || other.Allow,
+		Denials:         mergeDenials(output.Denials, other.Denials),
+		AuditResults:    append(append([]AuditResult{}, output.AuditResults...), other.AuditResults...),
+		Obligations:     append(append([]Obligation{}, output.Obligations...), other.Obligations...),
+		Headers:         mergeStringMaps(output.Headers, other.Headers),
+		ClaimsToForward: mergeInterfaceMaps(output.ClaimsToForward, other.ClaimsToForward),
+		TraceID:         output.TraceID,
 	}
-	if other.Deny != nil {
-		merged.Deny = other.Deny
+	if merged.TraceID == "" {
+		merged.TraceID = other.TraceID
 	}
 	return merged
 }
 
-// A Denial indicates the request should be denied (even if otherwise allowed).
+// mergeStringMaps merges b into a, with b's values taking precedence on key conflicts.
+func mergeStringMaps(a, b map[string]string) map[string]string {
+	if len(a) == 0 {
+		return b
+	}
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeInterfaceMaps merges b into a, with b's values taking precedence on key conflicts.
+func mergeInterfaceMaps(a, b map[string]interface{}) map[string]interface{} {
+	if len(a) == 0 {
+		return b
+	}
+	merged := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeDenials unions two lists of denial reasons by code. If both lists contain a reason with the
+// same code, the one with the higher (more severe) HTTP status is kept.
+func mergeDenials(a, b []Denial) []Denial {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+
+	order := make([]string, 0, len(a)+len(b))
+	byCode := make(map[string]Denial, len(a)+len(b))
+	for _, d := range append(append([]Denial{}, a...), b...) {
+		if existing, ok := byCode[d.Code]; !ok {
+			order = append(order, d.Code)
+			byCode[d.Code] = d
+		} else if d.Status > existing.Status {
+			byCode[d.Code] = d
+		}
+	}
+
+	merged := make([]Denial, 0, len(order))
+	for _, code := range order {
+		merged = append(merged, byCode[code])
+	}
+	return merged
+}
+
+// A Denial is a single, self-explanatory reason the request was (or would be) denied. A policy may
+// produce several Denials at once, e.g. one for a missing client certificate and one for a
+// group mismatch, so operators and the proxied UI can explain every matching deny rule rather than
+// just the first one encountered. Each deny rule reports one Denial, not one per criterion.
 type Denial struct {
-	Status  int
-	Message string
+	// Code is a stable, machine-readable identifier for the reason, e.g. "client_certificate_required"
+	// or "groups_mismatch". Codes are used to dedupe and prioritize Denials in Merge, and let callers
+	// key off a reason without parsing Message.
+	Code    string `json:"code"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	// Details carries reason-specific context (e.g. the groups that were required) for display or
+	// programmatic use. It is optional.
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// An Obligation is a side-effect the proxy must apply when access is granted: add or strip a
+// request header, set a response header, rewrite the JWT audience, tag the request for logging, or
+// require step-up authentication. Obligations let a criterion like require_mfa attach a condition
+// on access instead of denying the request outright.
+type Obligation struct {
+	Kind    string                 `json:"kind"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// A PolicyResult is the typed form of the JSON document returned by the top-level Rego query:
+// {allow, deny, obligations, headers, claims_to_forward, trace_id}.
+type PolicyResult struct {
+	Allow           bool                   `json:"allow"`
+	Deny            []Denial               `json:"deny"`
+	Obligations     []Obligation           `json:"obligations"`
+	Headers         map[string]string      `json:"headers"`
+	ClaimsToForward map[string]interface{} `json:"claims_to_forward"`
+	TraceID         string                 `json:"trace_id"`
 }
 
 // A PolicyEvaluator evaluates policies.
 type PolicyEvaluator struct {
+	store *Store
+
+	// baseQueries are prepared once at construction from the PPL-generated policy and any
+	// SubPolicies Rego, neither of which ever changes for the lifetime of a PolicyEvaluator.
+	baseQueries []rego.PreparedEvalQuery
+
+	bundleLoader *bundle.Loader
+
+	mu             sync.Mutex
+	bundleRevision string
+	// queries is baseQueries plus the bundle's queries, merged. It's cached alongside
+	// bundleRevision so a request doesn't re-merge/reallocate on every call, only when the bundle's
+	// revision actually changes.
 	queries []rego.PreparedEvalQuery
 }
 
+// A PolicyEvaluatorOption configures a PolicyEvaluator at construction time.
+type PolicyEvaluatorOption func(*policyEvaluatorConfig)
+
+type policyEvaluatorConfig struct {
+	bundleLoader *bundle.Loader
+}
+
+// WithBundleLoader adds the Rego modules active in loader to the evaluator, alongside the
+// PPL-generated base and any SubPolicies Rego. Unlike the base scripts, the bundle's modules are
+// re-read from loader (and their queries rebuilt) on every Evaluate call whose bundle revision has
+// changed since the last one, so a hot reload performed by loader actually reaches evaluation
+// instead of only updating loader.Status().
+func WithBundleLoader(loader *bundle.Loader) PolicyEvaluatorOption {
+	return func(c *policyEvaluatorConfig) { c.bundleLoader = loader }
+}
+
 // NewPolicyEvaluator creates a new PolicyEvaluator.
-func NewPolicyEvaluator(ctx context.Context, store *Store, configPolicy *config.Policy) (*PolicyEvaluator, error) {
-	e := new(PolicyEvaluator)
+func NewPolicyEvaluator(ctx context.Context, store *Store, configPolicy *config.Policy, opts ...PolicyEvaluatorOption) (*PolicyEvaluator, error) {
+	var cfg policyEvaluatorConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
 
 	// generate the base rego script for the policy
 	ppl := configPolicy.ToPPL()
@@ -71,37 +221,124 @@ func NewPolicyEvaluator(ctx context.Context, store *Store, configPolicy *config.
 		}
 	}
 
-	// for each script, create a rego and prepare a query.
+	baseQueries := make([]rego.PreparedEvalQuery, 0, len(scripts))
 	for _, script := range scripts {
-		r := rego.New(
-			rego.Store(store),
-			rego.Module("pomerium.policy", script),
-			rego.Query("result = data.pomerium.policy"),
-			getGoogleCloudServerlessHeadersRegoOption,
-			store.GetDataBrokerRecordOption(),
-		)
-
-		q, err := r.PrepareForEval(ctx)
+		q, err := newPreparedPolicyQuery(ctx, store, script)
 		if err != nil {
 			return nil, err
 		}
-		e.queries = append(e.queries, q)
+		baseQueries = append(baseQueries, q)
+	}
+
+	e := &PolicyEvaluator{
+		store:        store,
+		baseQueries:  baseQueries,
+		bundleLoader: cfg.bundleLoader,
+	}
+
+	if e.bundleLoader != nil {
+		// pick up whatever bundle is already active, so the first Evaluate doesn't pay the cost of
+		// detecting a change from the zero revision.
+		if _, err := e.allQueries(ctx); err != nil {
+			return nil, err
+		}
 	}
 
 	return e, nil
 }
 
+// newPreparedPolicyQuery prepares script for evaluation against store.
+func newPreparedPolicyQuery(ctx context.Context, store *Store, script string) (rego.PreparedEvalQuery, error) {
+	r := rego.New(
+		rego.Store(store),
+		rego.Module("pomerium.policy", script),
+		rego.Query("result = data.pomerium.policy"),
+		getGoogleCloudServerlessHeadersRegoOption,
+		store.GetDataBrokerRecordOption(),
+	)
+	return r.PrepareForEval(ctx)
+}
+
+// allQueries returns the evaluator's base queries plus its bundle's, rebuilding (and re-merging
+// with baseQueries) only when bundleLoader's active bundle has changed revision since the last
+// call. This is what makes bundleLoader's hot reload actually reach evaluation, rather than only
+// its Status(): it also loads the bundle's data document (if any) into the store, so e.g. a
+// distributed rbac roles/bindings tree takes effect alongside its .rego modules. If the newly-active
+// bundle fails to prepare (e.g. a bad push from a central policy team), the previously-active bundle
+// keeps being served, mirroring bundle.Loader's own handling of a failed poll, and the rebuild is
+// retried on the next call.
+func (e *PolicyEvaluator) allQueries(ctx context.Context) ([]rego.PreparedEvalQuery, error) {
+	if e.bundleLoader == nil {
+		return e.baseQueries, nil
+	}
+
+	revision := e.bundleLoader.Status().Revision
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if revision == e.bundleRevision && e.queries != nil {
+		return e.queries, nil
+	}
+
+	modules := e.bundleLoader.Modules()
+
+	// sort module paths so which module "wins" a conflicting header/claim merge doesn't depend on
+	// Go's randomized map iteration order
+	paths := make([]string, 0, len(modules))
+	for p := range modules {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	bundleQueries := make([]rego.PreparedEvalQuery, 0, len(paths))
+	for _, p := range paths {
+		q, err := newPreparedPolicyQuery(ctx, e.store, modules[p])
+		if err != nil {
+			if e.queries != nil {
+				log.Error(ctx).Err(err).Str("revision", revision).
+					Msg("authorize: failed to prepare newly-active bundle, continuing to serve the previous one")
+				return e.queries, nil
+			}
+			return nil, fmt.Errorf("authorize: preparing bundle module %s: %w", p, err)
+		}
+		bundleQueries = append(bundleQueries, q)
+	}
+
+	if data := e.bundleLoader.Data(); data != nil {
+		if err := e.store.SetBundleData(ctx, data); err != nil {
+			return nil, fmt.Errorf("authorize: loading bundle data document: %w", err)
+		}
+	}
+
+	e.queries = append(append([]rego.PreparedEvalQuery{}, e.baseQueries...), bundleQueries...)
+	e.bundleRevision = revision
+	return e.queries, nil
+}
+
 // Evaluate evaluates the policy rego scripts.
 func (e *PolicyEvaluator) Evaluate(ctx context.Context, input *PolicyInput) (*PolicyOutput, error) {
+	queries, err := e.allQueries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	output := new(PolicyOutput)
 	// run each query and merge the results
-	for _, query := range e.queries {
+	for _, query := range queries {
 		o, err := e.evaluateQuery(ctx, input, query)
 		if err != nil {
 			return nil, err
 		}
 		output = output.Merge(o)
 	}
+	for _, ar := range output.AuditResults {
+		log.Debug(ctx).
+			Str("enforcement-action", string(ar.Action)).
+			Bool("would-allow", ar.Allow).
+			Int("would-deny-reason-count", len(ar.Denials)).
+			Msg("authorize: dryrun/audit rule did not affect decision")
+	}
 	return output, nil
 }
 
@@ -115,20 +352,67 @@ func (e *PolicyEvaluator) evaluateQuery(ctx context.Context, input *PolicyInput,
 		return nil, fmt.Errorf("authorize: unexpected empty result from evaluating policy.rego")
 	}
 
-	return &PolicyOutput{
-		Allow: e.getAllow(rs[0].Bindings),
-		Deny:  e.getDeny(ctx, rs[0].Bindings),
-	}, nil
+	result, err := decodePolicyResult(rs[0].Bindings)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &PolicyOutput{
+		Allow:           result.Allow,
+		Denials:         result.Deny,
+		Obligations:     result.Obligations,
+		Headers:         result.Headers,
+		ClaimsToForward: result.ClaimsToForward,
+		TraceID:         result.TraceID,
+	}
+
+	if dryrunAllow, dryrunDeny := e.getAllow(rs[0].Bindings, "dryrun_allow"), e.getDenials(ctx, rs[0].Bindings, "dryrun_deny"); dryrunAllow || len(dryrunDeny) > 0 {
+		output.AuditResults = append(output.AuditResults, AuditResult{
+			Action:  parser.EnforcementActionDryRun,
+			Allow:   dryrunAllow,
+			Denials: dryrunDeny,
+		})
+	}
+	if auditAllow, auditDeny := e.getAllow(rs[0].Bindings, "audit_allow"), e.getDenials(ctx, rs[0].Bindings, "audit_deny"); auditAllow || len(auditDeny) > 0 {
+		output.AuditResults = append(output.AuditResults, AuditResult{
+			Action:  parser.EnforcementActionAudit,
+			Allow:   auditAllow,
+			Denials: auditDeny,
+		})
+	}
+
+	return output, nil
+}
+
+// decodePolicyResult decodes the "result" var into a PolicyResult. The Rego query binds result to
+// the entire pomerium.policy package, a plain map, so the simplest way to get a typed value out of
+// it is a JSON round-trip rather than hand-walking each field.
+func decodePolicyResult(vars rego.Vars) (*PolicyResult, error) {
+	m, ok := vars["result"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("authorize: unexpected result shape from evaluating policy.rego")
+	}
+
+	bs, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("authorize: marshaling policy result: %w", err)
+	}
+
+	var result PolicyResult
+	if err := json.Unmarshal(bs, &result); err != nil {
+		return nil, fmt.Errorf("authorize: unmarshaling policy result: %w", err)
+	}
+	return &result, nil
 }
 
-// getAllow gets the allow var. It expects a boolean.
-func (e *PolicyEvaluator) getAllow(vars rego.Vars) bool {
+// getAllow gets the named boolean var, e.g. "allow" or "dryrun_allow".
+func (e *PolicyEvaluator) getAllow(vars rego.Vars, key string) bool {
 	m, ok := vars["result"].(map[string]interface{})
 	if !ok {
 		return false
 	}
 
-	allow, ok := m["allow"].(bool)
+	allow, ok := m[key].(bool)
 	if !ok {
 		return false
 	}
@@ -136,27 +420,43 @@ func (e *PolicyEvaluator) getAllow(vars rego.Vars) bool {
 	return allow
 }
 
-// getDeny gets the deny var. It expects an (http status code, message) pair.
-func (e *PolicyEvaluator) getDeny(ctx context.Context, vars rego.Vars) *Denial {
+// getDenials gets the named deny var, e.g. "deny" or "dryrun_deny". It expects a set of reason
+// objects, each produced by a `deny[reason] { ... }` rule emitted by the generator for a matching
+// deny rule, with the shape {"code": ..., "status": ..., "message": ..., "details": ...}.
+func (e *PolicyEvaluator) getDenials(ctx context.Context, vars rego.Vars, key string) []Denial {
 	m, ok := vars["result"].(map[string]interface{})
 	if !ok {
 		return nil
 	}
 
-	pair, ok := m["deny"].([]interface{})
+	reasons, ok := m[key].([]interface{})
 	if !ok {
 		return nil
 	}
 
-	status, err := strconv.Atoi(fmt.Sprint(pair[0]))
-	if err != nil {
-		log.Error(ctx).Err(err).Msg("invalid type in deny")
-		return nil
-	}
-	msg := fmt.Sprint(pair[1])
+	var denials []Denial
+	for _, raw := range reasons {
+		reason, ok := raw.(map[string]interface{})
+		if !ok {
+			log.Error(ctx).Interface("reason", raw).Msg("invalid type in deny")
+			continue
+		}
+
+		status, err := strconv.Atoi(fmt.Sprint(reason["status"]))
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("invalid status in deny reason")
+			continue
+		}
 
-	return &Denial{
-		Status:  status,
-		Message: msg,
+		d := Denial{
+			Code:    fmt.Sprint(reason["code"]),
+			Status:  status,
+			Message: fmt.Sprint(reason["message"]),
+		}
+		if details, ok := reason["details"].(map[string]interface{}); ok {
+			d.Details = details
+		}
+		denials = append(denials, d)
 	}
+	return denials
 }
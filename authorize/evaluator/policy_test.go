@@ -0,0 +1,120 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/format"
+	"github.com/open-policy-agent/opa/rego"
+
+	"github.com/pomerium/pomerium/pkg/policy/generator"
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+// alwaysCriterion is a test-only Criterion that always holds, so a deny policy can be exercised
+// end-to-end without needing any particular request or session shape.
+type alwaysCriterion struct {
+	g *generator.Generator
+}
+
+func (alwaysCriterion) Names() []string { return []string{"always"} }
+
+func (c alwaysCriterion) GenerateRule(interface{}) (*ast.Rule, error) {
+	rule := c.g.NewRule("always")
+	rule.Body = ast.Body{ast.NewExpr(ast.BooleanTerm(true))}
+	return rule, nil
+}
+
+// newTestEvaluator builds a PolicyEvaluator for p directly against gen, bypassing
+// NewPolicyEvaluator (which requires a *config.Policy this package doesn't have a fixture for).
+func newTestEvaluator(ctx context.Context, t *testing.T, gen *generator.Generator, p *parser.Policy, store *Store) *PolicyEvaluator {
+	t.Helper()
+
+	mod, err := gen.Generate(p)
+	if err != nil {
+		t.Fatalf("generating rego: %v", err)
+	}
+	script, err := format.Ast(mod)
+	if err != nil {
+		t.Fatalf("formatting rego: %v", err)
+	}
+
+	q, err := newPreparedPolicyQuery(ctx, store, string(script))
+	if err != nil {
+		t.Fatalf("preparing query: %v", err)
+	}
+
+	return &PolicyEvaluator{store: store, baseQueries: []rego.PreparedEvalQuery{q}}
+}
+
+func TestPolicyEvaluatorEvaluate_Deny(t *testing.T) {
+	ctx := context.Background()
+
+	gen := generator.New(generator.WithCriterion(func(g *generator.Generator) generator.Criterion {
+		return alwaysCriterion{g: g}
+	}))
+
+	policy := &parser.Policy{
+		Rules: []parser.Rule{
+			{
+				Action: "deny",
+				And:    []parser.Criterion{{Name: "always"}},
+				Reason: parser.DenyReason{
+					Code:    "always_denied",
+					Status:  403,
+					Message: "this policy always denies",
+				},
+			},
+		},
+	}
+
+	e := newTestEvaluator(ctx, t, gen, policy, NewStore())
+
+	output, err := e.Evaluate(ctx, &PolicyInput{})
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+
+	if output.Allow {
+		t.Errorf("expected Allow=false, got true")
+	}
+	if len(output.Denials) != 1 {
+		t.Fatalf("expected exactly one denial, got %d: %+v", len(output.Denials), output.Denials)
+	}
+
+	d := output.Denials[0]
+	if d.Code != "always_denied" {
+		t.Errorf("expected code %q, got %q", "always_denied", d.Code)
+	}
+	if d.Status != 403 {
+		t.Errorf("expected status 403, got %d", d.Status)
+	}
+	if d.Message != "this policy always denies" {
+		t.Errorf("expected message %q, got %q", "this policy always denies", d.Message)
+	}
+}
+
+func TestPolicyEvaluatorEvaluate_AllowNoDenials(t *testing.T) {
+	ctx := context.Background()
+
+	gen := generator.New(generator.WithCriterion(func(g *generator.Generator) generator.Criterion {
+		return alwaysCriterion{g: g}
+	}))
+
+	policy := &parser.Policy{
+		Rules: []parser.Rule{
+			{Action: "allow", And: []parser.Criterion{{Name: "always"}}},
+		},
+	}
+
+	e := newTestEvaluator(ctx, t, gen, policy, NewStore())
+
+	output, err := e.Evaluate(ctx, &PolicyInput{})
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(output.Denials) != 0 {
+		t.Errorf("expected no denials, got %+v", output.Denials)
+	}
+}
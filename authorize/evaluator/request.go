@@ -0,0 +1,17 @@
+package evaluator
+
+// RequestHTTP is the portion of PolicyInput describing the HTTP request being authorized.
+type RequestHTTP struct {
+	Method   string            `json:"method" yaml:"method"`
+	Hostname string            `json:"hostname" yaml:"hostname"`
+	Path     string            `json:"path" yaml:"path"`
+	Headers  map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// RequestSession is the portion of PolicyInput describing the authenticated session, if any.
+type RequestSession struct {
+	ID     string   `json:"id" yaml:"id"`
+	User   string   `json:"user" yaml:"user"`
+	Email  string   `json:"email" yaml:"email"`
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+
+	"github.com/pomerium/pomerium/pkg/policy/rbac"
+)
+
+// A Store holds the OPA data document available to policy evaluation, such as RBAC roles and
+// bindings (SetRBAC) and bundle data (SetBundleData). It has no method that syncs databroker
+// records into it.
+type Store struct {
+	storage.Store
+}
+
+// NewStore creates a new, empty Store.
+func NewStore() *Store {
+	return &Store{Store: inmem.New()}
+}
+
+// GetDataBrokerRecordOption returns a rego.Option that makes the Store's whole data document
+// available to policy evaluation under data.
+func (s *Store) GetDataBrokerRecordOption() rego.Option {
+	return rego.Store(s.Store)
+}
+
+// SetRBAC replaces the roles and bindings available to the `rbac` criterion as
+// data.pomerium.rbac.roles and data.pomerium.rbac.bindings. It's typically called by
+// rbac.Watch so admins can update RBAC without redeploying.
+func (s *Store) SetRBAC(ctx context.Context, roles []rbac.Role, bindings []rbac.Binding) error {
+	return storage.Txn(ctx, s.Store, storage.WriteParams, func(txn storage.Transaction) error {
+		if err := storage.MakeDir(ctx, s.Store, txn, storage.Path{"pomerium", "rbac"}); err != nil {
+			return err
+		}
+		if err := s.Store.Write(ctx, txn, storage.AddOp, storage.Path{"pomerium", "rbac", "roles"}, roles); err != nil {
+			return err
+		}
+		return s.Store.Write(ctx, txn, storage.AddOp, storage.Path{"pomerium", "rbac", "bindings"}, bindings)
+	})
+}
+
+// SetBundleData replaces the data document contributed by an OPA bundle (bundle.Bundle.Data,
+// parsed from its data.json), overwriting each of its top-level keys in the store. It's called by
+// PolicyEvaluator whenever it activates a new bundle revision, so a distributed data document
+// (e.g. an rbac roles/bindings tree pushed the same way as SetRBAC) actually reaches evaluation
+// instead of sitting unused in bundle.Loader.Data().
+func (s *Store) SetBundleData(ctx context.Context, data map[string]interface{}) error {
+	return storage.Txn(ctx, s.Store, storage.WriteParams, func(txn storage.Transaction) error {
+		for key, value := range data {
+			if err := s.Store.Write(ctx, txn, storage.AddOp, storage.Path{key}, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
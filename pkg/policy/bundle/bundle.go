@@ -0,0 +1,90 @@
+// Package bundle loads signed OPA policy bundles (a tar.gz of .rego modules, a data.json, and a
+// .manifest) from a remote source and hands them to a PolicyEvaluator alongside the PPL-generated
+// base policy, so a central policy team can distribute Rego (including the rbac data document, see
+// pkg/policy/rbac) to a fleet of Pomerium instances without redeploying any of them.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// maxEntrySize caps how much of any single tar entry Parse will read, so a corrupt or malicious
+// bundle can't exhaust memory via decompression.
+const maxEntrySize = 64 * 1024 * 1024
+
+// A Manifest describes a Bundle, following OPA's .manifest convention.
+type Manifest struct {
+	Revision string   `json:"revision"`
+	Roots    []string `json:"roots,omitempty"`
+}
+
+// A Bundle is the parsed contents of an OPA bundle: its Rego modules, side-loaded data, and
+// manifest.
+type Bundle struct {
+	// Modules maps each module's path within the bundle (e.g. "rbac.rego") to its Rego source.
+	Modules map[string]string
+	// Data is the parsed contents of data.json, if the bundle contains one.
+	Data map[string]interface{}
+
+	Manifest Manifest
+}
+
+// Parse reads a bundle from its tar.gz representation.
+func Parse(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: opening gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	b := &Bundle{Modules: make(map[string]string)}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle: reading tar entry: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if hdr.Size > maxEntrySize {
+			return nil, fmt.Errorf("bundle: entry %s is %d bytes, exceeding the %d byte limit", hdr.Name, hdr.Size, maxEntrySize)
+		}
+
+		bs, err := ioutil.ReadAll(io.LimitReader(tr, maxEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("bundle: reading %s: %w", hdr.Name, err)
+		}
+
+		name := strings.TrimPrefix(hdr.Name, "/")
+		switch {
+		case name == ".manifest":
+			if err := json.Unmarshal(bs, &b.Manifest); err != nil {
+				return nil, fmt.Errorf("bundle: parsing .manifest: %w", err)
+			}
+		case path.Base(name) == "data.json":
+			if err := json.Unmarshal(bs, &b.Data); err != nil {
+				return nil, fmt.Errorf("bundle: parsing %s: %w", name, err)
+			}
+		case strings.HasSuffix(name, ".rego"):
+			b.Modules[name] = string(bs)
+		}
+	}
+
+	if b.Manifest.Revision == "" {
+		return nil, fmt.Errorf("bundle: missing revision in .manifest")
+	}
+	return b, nil
+}
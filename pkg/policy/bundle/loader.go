@@ -0,0 +1,117 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// A Status reports the health of a Loader's most recent poll, for the metrics endpoint.
+type Status struct {
+	Revision                 string
+	LastSuccessfulActivation time.Time
+	LastError                string
+}
+
+// A Loader polls a Source on an interval, verifies each fetched bundle, and atomically swaps it in
+// on success. If a poll fails (fetch, verify, or parse), the previously-active bundle is kept.
+type Loader struct {
+	source   Source
+	verifier Verifier
+	interval time.Duration
+
+	mu     sync.RWMutex
+	active *Bundle
+	status Status
+}
+
+// NewLoader creates a Loader that polls source every interval, verifying each fetched bundle
+// against verifier before activating it.
+func NewLoader(source Source, verifier Verifier, interval time.Duration) *Loader {
+	return &Loader{source: source, verifier: verifier, interval: interval}
+}
+
+// Run polls and activates bundles until ctx is canceled. It performs one poll immediately, so
+// Modules/Data are populated before Run's first tick.
+func (l *Loader) Run(ctx context.Context) error {
+	l.poll(ctx)
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			l.poll(ctx)
+		}
+	}
+}
+
+func (l *Loader) poll(ctx context.Context) {
+	if err := l.refresh(ctx); err != nil {
+		log.Error(ctx).Err(err).Msg("bundle: poll failed, keeping previously-active bundle")
+
+		l.mu.Lock()
+		l.status.LastError = err.Error()
+		l.mu.Unlock()
+	}
+}
+
+func (l *Loader) refresh(ctx context.Context) error {
+	data, signature, err := l.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := l.verifier.Verify(data, signature); err != nil {
+		return fmt.Errorf("verifying signature: %w", err)
+	}
+
+	b, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("parsing bundle: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active = b
+	l.status = Status{
+		Revision:                 b.Manifest.Revision,
+		LastSuccessfulActivation: time.Now(),
+	}
+	return nil
+}
+
+// Modules returns the Rego module sources of the currently-active bundle, keyed by path within the
+// bundle. It returns nil until the first successful poll.
+func (l *Loader) Modules() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.active == nil {
+		return nil
+	}
+	return l.active.Modules
+}
+
+// Data returns the side-loaded data document of the currently-active bundle, if any.
+func (l *Loader) Data() map[string]interface{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.active == nil {
+		return nil
+	}
+	return l.active.Data
+}
+
+// Status returns the Loader's current health.
+func (l *Loader) Status() Status {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.status
+}
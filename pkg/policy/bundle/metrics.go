@@ -0,0 +1,35 @@
+package bundle
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RegisterMetrics registers gauges reporting the Loader's bundle status with reg, so operators can
+// alert on a bundle that's stopped activating.
+func (l *Loader) RegisterMetrics(reg prometheus.Registerer) error {
+	revision := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "pomerium",
+		Subsystem: "bundle",
+		Name:      "last_successful_activation_timestamp_seconds",
+		Help:      "Unix timestamp of the bundle loader's last successful activation.",
+	}, func() float64 {
+		return float64(l.Status().LastSuccessfulActivation.Unix())
+	})
+
+	lastErr := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "pomerium",
+		Subsystem: "bundle",
+		Name:      "last_poll_errored",
+		Help:      "1 if the bundle loader's most recent poll failed, 0 otherwise.",
+	}, func() float64 {
+		if l.Status().LastError == "" {
+			return 0
+		}
+		return 1
+	})
+
+	for _, c := range []prometheus.Collector{revision, lastErr} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// maxFetchSize caps how much of a response body get() will read, so a compromised or misconfigured
+// bundle URL can't exhaust memory before the signature is ever checked.
+const maxFetchSize = 64 * 1024 * 1024
+
+// A Source fetches the raw tar.gz bytes of a bundle and its signature.
+type Source interface {
+	Fetch(ctx context.Context) (data, signature []byte, err error)
+}
+
+// An HTTPSource fetches a bundle from an HTTPS URL. The signature is expected alongside it at the
+// same URL with a ".sig" suffix. S3 and GCS sources can be added by implementing Source the same
+// way, using their respective SDKs.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// Fetch implements Source.
+func (s HTTPSource) Fetch(ctx context.Context) ([]byte, []byte, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	data, err := get(ctx, client, s.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bundle: fetching %s: %w", s.URL, err)
+	}
+	sig, err := get(ctx, client, s.URL+".sig")
+	if err != nil {
+		return nil, nil, fmt.Errorf("bundle: fetching %s.sig: %w", s.URL, err)
+	}
+	return data, sig, nil
+}
+
+func get(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(res.Body, maxFetchSize))
+}
@@ -0,0 +1,25 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"fmt"
+)
+
+// A Verifier checks a bundle's signature over its raw tar.gz bytes.
+type Verifier interface {
+	Verify(data, signature []byte) error
+}
+
+// An Ed25519Verifier verifies a bundle signed with an ed25519 private key, the simplest signing
+// scheme supported; other schemes can be added by implementing Verifier.
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+}
+
+// Verify implements Verifier.
+func (v Ed25519Verifier) Verify(data, signature []byte) error {
+	if !ed25519.Verify(v.PublicKey, data, signature) {
+		return fmt.Errorf("bundle: signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+// A conditionalGenerator generates the rule for one of a policy rule's and/or/not/nor criteria
+// lists, returning a rule other rules can reference by its Head.Name.
+type conditionalGenerator func(rules *ast.RuleSet, criteria []parser.Criterion) (*ast.Rule, error)
+
+// generateCriteriaRules generates and registers the rule for each criterion, along with any
+// obligations it contributes via ObligationEmitter, and returns the criteria rules in order so
+// callers can combine them.
+func (g *Generator) generateCriteriaRules(rules *ast.RuleSet, criteria []parser.Criterion) ([]*ast.Rule, error) {
+	criteriaRules := make([]*ast.Rule, 0, len(criteria))
+	for _, criterion := range criteria {
+		c, ok := g.GetCriterion(criterion.Name)
+		if !ok {
+			return nil, fmt.Errorf("generator: unknown criterion %q", criterion.Name)
+		}
+
+		rule, err := c.GenerateRule(criterion.Data)
+		if err != nil {
+			return nil, fmt.Errorf("generator: generating rule for criterion %q: %w", criterion.Name, err)
+		}
+		rules.Add(rule)
+		criteriaRules = append(criteriaRules, rule)
+
+		emitter, ok := c.(ObligationEmitter)
+		if !ok {
+			continue
+		}
+		obligationRule, err := emitter.EmitObligations(criterion.Data)
+		if err != nil {
+			return nil, fmt.Errorf("generator: emitting obligations for criterion %q: %w", criterion.Name, err)
+		}
+		if obligationRule == nil {
+			continue
+		}
+		// an obligation only applies once the criterion it came from actually holds
+		obligationRule.Body = append(ast.Body{ast.NewExpr(ast.VarTerm(string(rule.Head.Name)))}, obligationRule.Body...)
+		obligationRule.Head.Name = ast.Var("obligations")
+		rules.Add(obligationRule)
+	}
+	return criteriaRules, nil
+}
+
+// generateAndRule generates a rule that holds when every criterion holds.
+func (g *Generator) generateAndRule(rules *ast.RuleSet, criteria []parser.Criterion) (*ast.Rule, error) {
+	criteriaRules, err := g.generateCriteriaRules(rules, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := g.NewRule("and")
+	for _, cr := range criteriaRules {
+		rule.Body = append(rule.Body, ast.NewExpr(ast.VarTerm(string(cr.Head.Name))))
+	}
+	rules.Add(rule)
+	return rule, nil
+}
+
+// generateOrRule generates a rule that holds when any criterion holds, by adding one rule
+// definition per criterion under the same head name, the way Rego ORs multiple definitions of the
+// same (complete) rule together.
+func (g *Generator) generateOrRule(rules *ast.RuleSet, criteria []parser.Criterion) (*ast.Rule, error) {
+	criteriaRules, err := g.generateCriteriaRules(rules, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	head := g.NewRule("or")
+	for i, cr := range criteriaRules {
+		rule := head
+		if i > 0 {
+			rule = &ast.Rule{Head: &ast.Head{Name: head.Head.Name}}
+		}
+		rule.Body = ast.Body{ast.NewExpr(ast.VarTerm(string(cr.Head.Name)))}
+		rules.Add(rule)
+	}
+	return head, nil
+}
+
+// generateNotRule generates a rule that holds when at least one criterion does not hold, i.e. the
+// negation of generateAndRule.
+func (g *Generator) generateNotRule(rules *ast.RuleSet, criteria []parser.Criterion) (*ast.Rule, error) {
+	andRule, err := g.generateAndRule(rules, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := g.NewRule("not")
+	expr := ast.NewExpr(ast.VarTerm(string(andRule.Head.Name)))
+	expr.Negated = true
+	rule.Body = ast.Body{expr}
+	rules.Add(rule)
+	return rule, nil
+}
+
+// generateNorRule generates a rule that holds when every criterion fails to hold, i.e. the
+// negation of generateOrRule.
+func (g *Generator) generateNorRule(rules *ast.RuleSet, criteria []parser.Criterion) (*ast.Rule, error) {
+	orRule, err := g.generateOrRule(rules, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := g.NewRule("nor")
+	expr := ast.NewExpr(ast.VarTerm(string(orRule.Head.Name)))
+	expr.Negated = true
+	rule.Body = ast.Body{expr}
+	rules.Add(rule)
+	return rule, nil
+}
@@ -0,0 +1,30 @@
+package generator
+
+import "github.com/open-policy-agent/opa/ast"
+
+// A Criterion generates the Rego for a single named condition in a PPL rule (e.g. `domain`,
+// `groups`, `rbac`). Criteria are registered with a Generator via WithCriterion, and referenced by
+// the `and`/`or`/`not`/`nor` conditional generators while building each policy rule.
+type Criterion interface {
+	// Names returns the PPL criterion names this Criterion handles. Most handle exactly one.
+	Names() []string
+	// GenerateRule generates the rule evaluating this criterion for the given PPL criterion data
+	// (the unmarshaled YAML value following the criterion's name, e.g. `{resource: widgets,
+	// action: read}` for `- rbac: {resource: widgets, action: read}`).
+	GenerateRule(data interface{}) (*ast.Rule, error)
+}
+
+// A CriterionConstructor constructs a Criterion bound to the given Generator, so a Criterion can
+// allocate uniquely-named rules via Generator.NewRule.
+type CriterionConstructor func(*Generator) Criterion
+
+// An ObligationEmitter is an optional interface a Criterion may implement to attach obligations
+// (side-effects the proxy must apply when access is granted, e.g. a header to inject) to the
+// policy result instead of, or in addition to, contributing to allow/deny. For example, a
+// require_mfa criterion can attach a step-up-auth obligation rather than failing the request
+// outright. Criteria that only ever allow or deny don't need to implement this.
+type ObligationEmitter interface {
+	// EmitObligations generates the rule that writes into the `obligations` partial set when this
+	// criterion's condition holds, given the criterion's PPL data.
+	EmitObligations(data interface{}) (*ast.Rule, error)
+}
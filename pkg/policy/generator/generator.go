@@ -3,7 +3,9 @@ package generator
 
 import (
 	"fmt"
+	"net/http"
 	"sort"
+	"strconv"
 
 	"github.com/open-policy-agent/opa/ast"
 
@@ -47,19 +49,63 @@ func (g *Generator) GetCriterion(name string) (Criterion, bool) {
 	return c, ok
 }
 
+// CriteriaNames returns the names of every Criterion registered with the Generator, sorted. It
+// lets callers (e.g. tester.ComputeCoverage) enumerate what GetCriterion can look up, since
+// Generator otherwise only exposes lookup by name.
+func (g *Generator) CriteriaNames() []string {
+	names := make([]string, 0, len(g.criteria))
+	for name := range g.criteria {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Generate generates the rego module from a policy.
 func (g *Generator) Generate(policy *parser.Policy) (*ast.Module, error) {
 	rules := ast.NewRuleSet()
 	rules.Add(ast.MustParseRule(`default allow = false`))
-	rules.Add(ast.MustParseRule(`default deny = false`))
+	// deny is a partial set of reason objects rather than a single status+message pair, so that
+	// several deny rules (or several sub-policies) can report every reason at once. Each deny
+	// policy rule contributes one `deny[reason] { ... }` rule, built in the loop below, whose single
+	// reason is added when that rule's criteria hold; individual criteria do not add reasons of their
+	// own. It has no `default`: `default` only applies to complete rules, and a partial
+	// set that's never defined for a given input already evaluates to the empty set.
+	// dryrun_* and audit_* mirror allow/deny, but are only ever written to by rules scoped with
+	// EnforcementActionDryRun/EnforcementActionAudit below, so they can never affect the
+	// request-time decision. dryrun_deny/audit_deny are partial sets too, same as deny above, so
+	// they get no default for the same reason.
+	rules.Add(ast.MustParseRule(`default dryrun_allow = false`))
+	rules.Add(ast.MustParseRule(`default audit_allow = false`))
+	// obligations is a partial set of side-effects (header injection, JWT audience rewrites, step-up
+	// auth, ...) the proxy must apply on allow. Criteria implementing ObligationEmitter contribute to
+	// it independently of whatever rule they're used in, via generateCriteriaRules below. Like deny,
+	// it gets no default: a criterion contributes an obligations[ob] partial set rule whenever a
+	// policy rule uses it, and default only applies to complete rules.
+	// headers, claims_to_forward, and trace_id are extension points alongside obligations: no
+	// built-in criterion writes into them yet, so unlike obligations they're still declared as
+	// complete rules defaulting to empty. If a criterion is ever given a reason to partial-define one
+	// of these heads instead, its default here has to come out the same way obligations' did.
+	rules.Add(ast.MustParseRule(`default headers = {}`))
+	rules.Add(ast.MustParseRule(`default claims_to_forward = {}`))
+	rules.Add(ast.MustParseRule(`default trace_id = ""`))
+
+	for i, policyRule := range policy.Rules {
+		isDeny := policyRule.Action == "deny"
 
-	for _, policyRule := range policy.Rules {
 		rule := &ast.Rule{
 			Head: &ast.Head{
-				Name:  ast.Var(policyRule.Action),
-				Value: ast.VarTerm("v"),
+				Name: ast.Var(ruleHeadName(policyRule.Action, policyRule.EnforcementAction)),
 			},
 		}
+		if isDeny {
+			// deny (and dryrun_deny/audit_deny) are partial sets of reason objects: rule.Head.Key
+			// names the term each matching rule contributes, rather than Head.Value naming the
+			// single value a complete rule like allow evaluates to.
+			rule.Head.Key = ast.VarTerm("reason")
+		} else {
+			rule.Head.Value = ast.VarTerm("v")
+		}
 
 		fields := []struct {
 			criteria  []parser.Criterion
@@ -78,12 +124,16 @@ func (g *Generator) Generate(policy *parser.Policy) (*ast.Module, error) {
 			if err != nil {
 				return nil, err
 			}
-			if len(rule.Body) == 0 {
+			if !isDeny && len(rule.Body) == 0 {
 				rule.Body = append(rule.Body, ast.Assign.Expr(ast.VarTerm("v"), ast.VarTerm(string(subRule.Head.Name))))
 			}
 			rule.Body = append(rule.Body, ast.NewExpr(ast.VarTerm(string(subRule.Head.Name))))
 		}
 
+		if isDeny {
+			rule.Body = append(rule.Body, ast.Assign.Expr(ast.VarTerm("reason"), denyReasonTerm(policyRule, i)))
+		}
+
 		rules.Add(rule)
 	}
 
@@ -113,6 +163,45 @@ func (g *Generator) Generate(policy *parser.Policy) (*ast.Module, error) {
 	return mod, nil
 }
 
+// ruleHeadName returns the Rego rule head that a policy rule's decision should be written into,
+// based on its EnforcementAction. Rules scoped to dryrun or audit are kept out of the enforced
+// allow/deny heads entirely, rather than being evaluated normally and discarded, so a bug in the
+// evaluator can never let one of them block a request.
+func ruleHeadName(action string, enforcement parser.EnforcementAction) string {
+	switch enforcement {
+	case parser.EnforcementActionDryRun:
+		return "dryrun_" + action
+	case parser.EnforcementActionAudit:
+		return "audit_" + action
+	default:
+		return action
+	}
+}
+
+// denyReasonTerm builds the reason object a deny rule's Head.Key term is assigned to, matching the
+// shape evaluator.PolicyEvaluator.getDenials expects: {"code", "status", "message"}. index is the
+// rule's position within the policy, used as a fallback Code so every deny rule still reports a
+// distinct, stable reason even if the PPL author didn't set one.
+func denyReasonTerm(rule parser.Rule, index int) *ast.Term {
+	code := rule.Reason.Code
+	if code == "" {
+		code = fmt.Sprintf("deny_%d", index)
+	}
+	status := rule.Reason.Status
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+	message := rule.Reason.Message
+	if message == "" {
+		message = code
+	}
+
+	return ast.MustParseTerm(fmt.Sprintf(
+		`{"code": %s, "status": %d, "message": %s}`,
+		strconv.Quote(code), status, strconv.Quote(message),
+	))
+}
+
 // NewRule creates a new rule with a dynamically generated name.
 func (g *Generator) NewRule(name string) *ast.Rule {
 	id := g.ids[name]
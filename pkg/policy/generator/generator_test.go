@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/format"
+	"github.com/open-policy-agent/opa/rego"
+
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+// stepUpCriterion is a test-only Criterion that also implements ObligationEmitter, to verify
+// Generate actually wires EmitObligations into the module instead of leaving it dead code.
+type stepUpCriterion struct {
+	g *Generator
+}
+
+func (stepUpCriterion) Names() []string { return []string{"step_up"} }
+
+func (c stepUpCriterion) GenerateRule(interface{}) (*ast.Rule, error) {
+	rule := c.g.NewRule("step_up")
+	rule.Body = ast.Body{ast.NewExpr(ast.BooleanTerm(true))}
+	return rule, nil
+}
+
+func (c stepUpCriterion) EmitObligations(interface{}) (*ast.Rule, error) {
+	rule := c.g.NewRule("step_up_obligation")
+	rule.Head.Key = ast.VarTerm("ob")
+	rule.Body = ast.Body{ast.Assign.Expr(ast.VarTerm("ob"), ast.MustParseTerm(`{"kind": "require_mfa"}`))}
+	return rule, nil
+}
+
+func TestGenerateWiresObligations(t *testing.T) {
+	g := New(WithCriterion(func(g *Generator) Criterion { return stepUpCriterion{g: g} }))
+
+	mod, err := g.Generate(&parser.Policy{
+		Rules: []parser.Rule{
+			{Action: "allow", And: []parser.Criterion{{Name: "step_up"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	bs, err := format.Ast(mod)
+	if err != nil {
+		t.Fatalf("format.Ast: %v", err)
+	}
+
+	if !strings.Contains(string(bs), `obligations[ob]`) {
+		t.Errorf("expected generated module to contain an obligations[ob] rule from the step_up criterion, got:\n%s", bs)
+	}
+
+	// A text match alone wouldn't have caught `default obligations = set()` conflicting with the
+	// obligations[ob] partial set above: PrepareForEval is what the evaluator actually calls, and
+	// that's where OPA rejects a name that's both a default-complete rule and a partial set.
+	if _, err := rego.New(rego.Module("pomerium.policy", string(bs)), rego.Query("data.pomerium.policy")).PrepareForEval(context.Background()); err != nil {
+		t.Errorf("PrepareForEval: %v", err)
+	}
+}
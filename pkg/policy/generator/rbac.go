@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// rbacCriterion implements the `rbac` PPL criterion: `- rbac: {resource: ..., action: ...}`. It
+// grants access if the roles and bindings loaded into the OPA data document (see
+// pkg/policy/rbac and authorize/evaluator.Store.SetRBAC) bind the session's user to a role with
+// the requested permission, rather than hard-coding roles in PPL itself.
+type rbacCriterion struct {
+	g *Generator
+}
+
+// NewRBAC constructs the `rbac` Criterion. Register it with generator.WithCriterion(generator.NewRBAC).
+func NewRBAC(g *Generator) Criterion {
+	return &rbacCriterion{g: g}
+}
+
+// Names implements Criterion.
+func (*rbacCriterion) Names() []string {
+	return []string{"rbac"}
+}
+
+// GenerateRule implements Criterion.
+func (c *rbacCriterion) GenerateRule(data interface{}) (*ast.Rule, error) {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rbac: criterion data must be an object with resource and action fields")
+	}
+
+	resource, _ := fields["resource"].(string)
+	action, _ := fields["action"].(string)
+	if resource == "" || action == "" {
+		return nil, fmt.Errorf("rbac: resource and action fields are required")
+	}
+
+	rule := c.g.NewRule("rbac")
+	// binding/role/permission are bound by `:=` against a wildcard index, which already declares
+	// them; a `some` on the same var name here would redeclare it and the compiler rejects that.
+	rule.Body = ast.MustParseBody(fmt.Sprintf(`
+		binding := data.pomerium.rbac.bindings[_]
+		binding.subject == input.session.user
+		role := data.pomerium.rbac.roles[_]
+		role.name == binding.role
+		permission := role.permissions[_]
+		permission.resource == %q
+		permission.action == %q
+	`, resource, action))
+	return rule, nil
+}
@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/format"
+	"github.com/open-policy-agent/opa/rego"
+
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+// TestRBACGenerateRuleCompiles guards the rbac criterion's generated body against compile errors
+// such as a `some x` that redeclares a var already bound by `:=` against a wildcard index, which
+// has no generate/eval coverage anywhere else (only parser_test.go parses an `rbac` criterion, it
+// never generates or evaluates one).
+func TestRBACGenerateRuleCompiles(t *testing.T) {
+	g := New(WithCriterion(NewRBAC))
+
+	mod, err := g.Generate(&parser.Policy{
+		Rules: []parser.Rule{
+			{
+				Action: "allow",
+				And: []parser.Criterion{
+					{Name: "rbac", Data: map[string]interface{}{"resource": "widgets", "action": "read"}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	bs, err := format.Ast(mod)
+	if err != nil {
+		t.Fatalf("format.Ast: %v", err)
+	}
+
+	if _, err := rego.New(rego.Module("pomerium.policy", string(bs)), rego.Query("data.pomerium.policy")).PrepareForEval(context.Background()); err != nil {
+		t.Fatalf("PrepareForEval: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// Package parser defines the abstract syntax for Pomerium Policy Language (PPL), the YAML-based
+// rule language that pkg/policy/generator compiles into Rego.
+package parser
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Policy is a parsed PPL document: an ordered list of allow/deny rules.
+type Policy struct {
+	Rules []Rule
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. A PPL document is a mapping from rule name ("allow",
+// "deny", "dryrun_allow", "dryrun_deny", "audit_allow", "audit_deny") to that rule's criteria,
+// mirroring the Rego rule heads the generator produces for each (generator.ruleHeadName). Rules
+// are kept in document order.
+func (p *Policy) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("parser: policy must be a mapping of rule name to criteria")
+	}
+
+	p.Rules = nil
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var name string
+		if err := node.Content[i].Decode(&name); err != nil {
+			return fmt.Errorf("parser: decoding rule name: %w", err)
+		}
+
+		action, enforcement, err := splitRuleName(name)
+		if err != nil {
+			return err
+		}
+
+		var body ruleBody
+		if err := node.Content[i+1].Decode(&body); err != nil {
+			return fmt.Errorf("parser: decoding %s: %w", name, err)
+		}
+
+		p.Rules = append(p.Rules, Rule{
+			Action:            action,
+			And:               body.And,
+			Or:                body.Or,
+			Not:               body.Not,
+			Nor:               body.Nor,
+			EnforcementAction: enforcement,
+			Reason:            body.Reason,
+		})
+	}
+	return nil
+}
+
+// Parse parses a PPL YAML document into a Policy.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parser: %w", err)
+	}
+	return &p, nil
+}
+
+// splitRuleName maps a PPL document's top-level rule name to the Action and EnforcementAction it
+// represents, the reverse of generator.ruleHeadName.
+func splitRuleName(name string) (action string, enforcement EnforcementAction, err error) {
+	switch name {
+	case "allow":
+		return "allow", EnforcementActionEnforce, nil
+	case "deny":
+		return "deny", EnforcementActionEnforce, nil
+	case "dryrun_allow":
+		return "allow", EnforcementActionDryRun, nil
+	case "dryrun_deny":
+		return "deny", EnforcementActionDryRun, nil
+	case "audit_allow":
+		return "allow", EnforcementActionAudit, nil
+	case "audit_deny":
+		return "deny", EnforcementActionAudit, nil
+	default:
+		return "", "", fmt.Errorf("parser: unknown rule name %q", name)
+	}
+}
+
+// ruleBody is the YAML shape of a single rule name's value in a PPL document.
+type ruleBody struct {
+	And    []Criterion `yaml:"and"`
+	Or     []Criterion `yaml:"or"`
+	Not    []Criterion `yaml:"not"`
+	Nor    []Criterion `yaml:"nor"`
+	Reason DenyReason  `yaml:"reason"`
+}
+
+// A Rule is a single allow or deny rule. Exactly one of And, Or, Not, or Nor is typically set,
+// mirroring the PPL YAML shape of `allow: {and: [...]}` / `{or: [...]}` / etc.
+type Rule struct {
+	// Action is the rule's decision, "allow" or "deny".
+	Action string
+
+	And []Criterion
+	Or  []Criterion
+	Not []Criterion
+	Nor []Criterion
+
+	// EnforcementAction controls whether this rule can affect the request-time decision, or only
+	// observes what it would have decided. It defaults to EnforcementActionEnforce.
+	EnforcementAction EnforcementAction
+
+	// Reason customizes the denial object a "deny" rule contributes to the deny partial set. It is
+	// ignored for "allow" rules.
+	Reason DenyReason
+}
+
+// A DenyReason customizes the denial object a deny Rule's generated Rego contributes to the deny
+// partial set (see evaluator.Denial). Any field left unset is defaulted by the generator: Code
+// falls back to the rule's position in the policy, Status to 403, and Message to Code.
+type DenyReason struct {
+	Code    string `yaml:"code"`
+	Status  int    `yaml:"status"`
+	Message string `yaml:"message"`
+}
+
+// A Criterion is a single named condition within a Rule, such as `domain`, `groups`, or `rbac`.
+type Criterion struct {
+	Name string
+	Data interface{}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. A Criterion is a single-key mapping of its name to its
+// data, e.g. `rbac: {resource: widgets, action: read}`.
+func (c *Criterion) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return fmt.Errorf("parser: criterion must be a single-key mapping of name to data")
+	}
+
+	if err := node.Content[0].Decode(&c.Name); err != nil {
+		return fmt.Errorf("parser: decoding criterion name: %w", err)
+	}
+
+	var data interface{}
+	if err := node.Content[1].Decode(&data); err != nil {
+		return fmt.Errorf("parser: decoding criterion %q data: %w", c.Name, err)
+	}
+	c.Data = data
+	return nil
+}
+
+// An EnforcementAction controls what a Rule does when it matches, so operators can roll out a new
+// rule by observing its effect before it can block any request.
+type EnforcementAction string
+
+const (
+	// EnforcementActionEnforce blocks (or allows) the request as usual. This is the default.
+	EnforcementActionEnforce EnforcementAction = "enforce"
+	// EnforcementActionDryRun never affects Allow/Deny, but records the decision the rule would
+	// have made.
+	EnforcementActionDryRun EnforcementAction = "dryrun"
+	// EnforcementActionAudit never affects Allow/Deny, but records the decision the rule would
+	// have made, per request, the same way EnforcementActionDryRun does. No metric or event is
+	// emitted for it.
+	EnforcementActionAudit EnforcementAction = "audit"
+)
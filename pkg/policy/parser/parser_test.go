@@ -0,0 +1,29 @@
+package parser
+
+import "testing"
+
+func TestParseEnforcementAction(t *testing.T) {
+	p, err := Parse([]byte(`
+dryrun_deny:
+  and:
+    - rbac: {resource: widgets, action: delete}
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(p.Rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(p.Rules))
+	}
+
+	rule := p.Rules[0]
+	if rule.Action != "deny" {
+		t.Errorf("expected action %q, got %q", "deny", rule.Action)
+	}
+	if rule.EnforcementAction != EnforcementActionDryRun {
+		t.Errorf("expected enforcement action %q, got %q", EnforcementActionDryRun, rule.EnforcementAction)
+	}
+	if len(rule.And) != 1 || rule.And[0].Name != "rbac" {
+		t.Fatalf("expected a single rbac criterion, got %+v", rule.And)
+	}
+}
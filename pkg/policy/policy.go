@@ -0,0 +1,35 @@
+// Package policy wires together the PPL criteria Pomerium ships and exposes the single Generator
+// construction used everywhere a PPL policy needs to become Rego: policy evaluation itself, the
+// golden-file/--trace tooling in pkg/policy/tester, and anything else that needs to render the same
+// module the evaluator would.
+package policy
+
+import (
+	"github.com/open-policy-agent/opa/format"
+
+	"github.com/pomerium/pomerium/pkg/policy/generator"
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+// DefaultGenerator is the Generator used to compile PPL into Rego everywhere in Pomerium. Anything
+// that needs to render or evaluate a policy's Rego should use this Generator (or GenerateRegoFromPolicy
+// below) rather than constructing its own, so criteria are registered consistently and a golden file
+// or --trace output always matches what evaluation actually runs.
+var DefaultGenerator = generator.New(
+	generator.WithCriterion(generator.NewRBAC),
+)
+
+// GenerateRegoFromPolicy compiles p with DefaultGenerator and formats the result, ready to pass to
+// rego.Module.
+func GenerateRegoFromPolicy(p *parser.Policy) (string, error) {
+	mod, err := DefaultGenerator.Generate(p)
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := format.Ast(mod)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
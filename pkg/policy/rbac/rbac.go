@@ -0,0 +1,45 @@
+// Package rbac loads the roles and role bindings consulted by the `rbac` PPL criterion, so admins
+// can manage role-based access without hand-writing Rego.
+package rbac
+
+// A Role grants a set of (resource, action) permissions.
+type Role struct {
+	Name        string       `json:"name" yaml:"name"`
+	Permissions []Permission `json:"permissions" yaml:"permissions"`
+}
+
+// A Permission is a single (resource, action) pair granted by a Role.
+type Permission struct {
+	Resource string `json:"resource" yaml:"resource"`
+	Action   string `json:"action" yaml:"action"`
+}
+
+// A Binding grants a Role to a subject. Subject is matched against the session's user ID.
+type Binding struct {
+	Role    string `json:"role" yaml:"role"`
+	Subject string `json:"subject" yaml:"subject"`
+}
+
+// Allows reports whether any Role bound to subject (via bindings) grants (resource, action).
+func Allows(roles []Role, bindings []Binding, subject, resource, action string) bool {
+	byName := make(map[string]Role, len(roles))
+	for _, r := range roles {
+		byName[r.Name] = r
+	}
+
+	for _, b := range bindings {
+		if b.Subject != subject {
+			continue
+		}
+		role, ok := byName[b.Role]
+		if !ok {
+			continue
+		}
+		for _, p := range role.Permissions {
+			if p.Resource == resource && p.Action == action {
+				return true
+			}
+		}
+	}
+	return false
+}
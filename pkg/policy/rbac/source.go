@@ -0,0 +1,67 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// A Source provides the current RBAC roles and bindings, e.g. from a file or a databroker record.
+type Source interface {
+	Load(ctx context.Context) (roles []Role, bindings []Binding, err error)
+}
+
+// A Sink receives updated RBAC data. *evaluator.Store satisfies this.
+type Sink interface {
+	SetRBAC(ctx context.Context, roles []Role, bindings []Binding) error
+}
+
+// A FileSource loads RBAC data from a YAML file of the form `{roles: [...], bindings: [...]}`.
+type FileSource struct {
+	Path string
+}
+
+// Load implements Source.
+func (s FileSource) Load(ctx context.Context) ([]Role, []Binding, error) {
+	bs, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rbac: reading %s: %w", s.Path, err)
+	}
+
+	var doc struct {
+		Roles    []Role    `yaml:"roles"`
+		Bindings []Binding `yaml:"bindings"`
+	}
+	if err := yaml.Unmarshal(bs, &doc); err != nil {
+		return nil, nil, fmt.Errorf("rbac: parsing %s: %w", s.Path, err)
+	}
+	return doc.Roles, doc.Bindings, nil
+}
+
+// Watch polls src every interval and pushes any successfully loaded data to sink, until ctx is
+// canceled. A load error is logged and skipped rather than treated as fatal, so a transient
+// failure (e.g. the databroker being briefly unreachable) doesn't stop RBAC updates for good.
+func Watch(ctx context.Context, src Source, sink Sink, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		roles, bindings, err := src.Load(ctx)
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("rbac: failed to load roles and bindings")
+		} else if err := sink.SetRBAC(ctx, roles, bindings); err != nil {
+			return fmt.Errorf("rbac: applying update: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package tester
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// Command returns the `pomerium policy test` command, which runs the fixtures in a directory
+// against the Rego generated for each case's policy.
+func Command() *cobra.Command {
+	var trace, update, coverage bool
+
+	cmd := &cobra.Command{
+		Use:   "test DIR",
+		Short: "Run policy test fixtures against the generated rego",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cases, err := LoadSuite(args[0])
+			if err != nil {
+				return err
+			}
+
+			if update {
+				return UpdateGolden(cases)
+			}
+
+			results, err := Run(cmd.Context(), cases)
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, r := range results {
+				status := "PASS"
+				if !r.Pass {
+					status = "FAIL"
+					failed++
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s  %s\n", status, r.Case.Name)
+				if r.Pass {
+					continue
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "    %s\n", r.Failure)
+				if !trace {
+					continue
+				}
+				if out, err := Trace(cmd.Context(), r.Case); err != nil {
+					fmt.Fprintf(cmd.OutOrStdout(), "    (trace unavailable: %v)\n", err)
+				} else {
+					fmt.Fprintln(cmd.OutOrStdout(), out)
+				}
+			}
+
+			if coverage {
+				printCoverage(cmd.OutOrStdout(), cases)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d cases failed", failed, len(results))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&trace, "trace", false, "print OPA's evaluation trace for each failing case")
+	cmd.Flags().BoolVar(&update, "update", false, "regenerate golden files referenced by the suite instead of checking them")
+	cmd.Flags().BoolVar(&coverage, "coverage", false, "report which registered PPL criteria the suite's cases exercise")
+
+	return cmd
+}
+
+// printCoverage writes the suite's criteria coverage against DefaultGenerator's registered
+// criteria, the same generator RunCase evaluates against.
+func printCoverage(w io.Writer, cases []Case) {
+	cov := ComputeCoverage(DefaultGenerator, cases, DefaultGenerator.CriteriaNames())
+
+	fmt.Fprintln(w, "\ncriteria coverage:")
+	for _, name := range cov.Exercised {
+		fmt.Fprintf(w, "  covered      %s\n", name)
+	}
+	for _, name := range cov.Missing {
+		fmt.Fprintf(w, "  not covered  %s\n", name)
+	}
+}
@@ -0,0 +1,44 @@
+package tester
+
+import (
+	"sort"
+
+	"github.com/pomerium/pomerium/pkg/policy/generator"
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+// Coverage reports which of a known set of PPL criteria a suite's cases actually exercise.
+type Coverage struct {
+	Exercised []string
+	Missing   []string
+}
+
+// ComputeCoverage walks every case's policy for criteria registered with gen, and reports which of
+// allCriteriaNames were exercised by at least one case. allCriteriaNames is caller-supplied, e.g.
+// gen.CriteriaNames() for every criterion the Generator knows.
+func ComputeCoverage(gen *generator.Generator, cases []Case, allCriteriaNames []string) Coverage {
+	exercised := make(map[string]bool)
+	for _, c := range cases {
+		for _, rule := range c.Policy.ToPPL().Rules {
+			for _, criteria := range [][]parser.Criterion{rule.And, rule.Or, rule.Not, rule.Nor} {
+				for _, criterion := range criteria {
+					if _, ok := gen.GetCriterion(criterion.Name); ok {
+						exercised[criterion.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	var cov Coverage
+	for _, name := range allCriteriaNames {
+		if exercised[name] {
+			cov.Exercised = append(cov.Exercised, name)
+		} else {
+			cov.Missing = append(cov.Missing, name)
+		}
+	}
+	sort.Strings(cov.Exercised)
+	sort.Strings(cov.Missing)
+	return cov
+}
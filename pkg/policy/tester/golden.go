@@ -0,0 +1,66 @@
+package tester
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/open-policy-agent/opa/format"
+
+	"github.com/pomerium/pomerium/pkg/policy"
+)
+
+// DefaultGenerator renders the Rego module used for golden-file comparisons and --trace. It's the
+// same Generator construction RunCase evaluates against (pkg/policy.DefaultGenerator), so a case
+// exercising a criterion like rbac renders and evaluates identically instead of diverging from a
+// bare, criterion-less Generator.
+var DefaultGenerator = policy.DefaultGenerator
+
+func renderRego(c Case) ([]byte, error) {
+	mod, err := DefaultGenerator.Generate(c.Policy.ToPPL())
+	if err != nil {
+		return nil, fmt.Errorf("generating rego: %w", err)
+	}
+	return format.Ast(mod)
+}
+
+// checkGolden compares the Rego generated for c.Policy against c.Golden, read relative to the
+// fixture file's directory.
+func checkGolden(c Case) error {
+	got, err := renderRego(c)
+	if err != nil {
+		return err
+	}
+
+	goldenPath := filepath.Join(filepath.Dir(c.path), c.Golden)
+	want, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		return fmt.Errorf("reading golden file %s: %w", goldenPath, err)
+	}
+
+	if string(got) != string(want) {
+		return fmt.Errorf("generated rego does not match golden file %s", goldenPath)
+	}
+	return nil
+}
+
+// UpdateGolden regenerates and overwrites every golden file referenced by cases. Cases without a
+// Golden are skipped.
+func UpdateGolden(cases []Case) error {
+	for _, c := range cases {
+		if c.Golden == "" {
+			continue
+		}
+
+		bs, err := renderRego(c)
+		if err != nil {
+			return fmt.Errorf("tester: generating rego for %s: %w", c.Name, err)
+		}
+
+		goldenPath := filepath.Join(filepath.Dir(c.path), c.Golden)
+		if err := ioutil.WriteFile(goldenPath, bs, 0o644); err != nil {
+			return fmt.Errorf("tester: writing golden file %s: %w", goldenPath, err)
+		}
+	}
+	return nil
+}
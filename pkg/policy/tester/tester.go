@@ -0,0 +1,145 @@
+// Package tester runs fixture-driven tests against the Rego that pkg/policy/generator produces
+// for a policy (PPL plus any custom SubPolicies Rego), so operators can catch regressions before
+// deploying a policy change. A suite is a directory of YAML cases; each case names the policy
+// under test, its input, and the outcome it expects, mirroring how Gatekeeper's `gator verify`
+// structures constraint test suites.
+package tester
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+)
+
+// A Case is a single test: a policy, the input to evaluate it against, and the outcome expected.
+type Case struct {
+	// Name identifies the case in test output. It defaults to the fixture's file name.
+	Name string `yaml:"name"`
+	// Policy is the policy under test, in the same shape as a route's policy in the Pomerium config.
+	Policy config.Policy `yaml:"policy"`
+	// Input is the PolicyInput the policy is evaluated against.
+	Input evaluator.PolicyInput `yaml:"input"`
+	// Expect is the outcome the policy must produce.
+	Expect Expectation `yaml:"expect"`
+	// Golden, if set, is a path (relative to the fixture file) to a golden copy of the Rego module
+	// generated for Policy. The case fails if the generated module's text no longer matches.
+	Golden string `yaml:"golden"`
+
+	path string
+}
+
+// An Expectation is the outcome a Case expects.
+type Expectation struct {
+	Allow bool `yaml:"allow"`
+	// Deny, if non-empty, is the set of denial codes (see evaluator.Denial.Code) the case expects,
+	// in any order. An empty Deny only asserts that no denial is produced.
+	Deny []string `yaml:"deny"`
+}
+
+// A Result is the outcome of running a single Case.
+type Result struct {
+	Case    Case
+	Pass    bool
+	Failure string
+	Output  *evaluator.PolicyOutput
+}
+
+// LoadSuite reads every *.yaml/*.yml fixture in dir (non-recursive) as a Case.
+func LoadSuite(dir string) ([]Case, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	ymlMatches, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	matches = append(matches, ymlMatches...)
+	sort.Strings(matches)
+
+	var cases []Case
+	for _, path := range matches {
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("tester: reading %s: %w", path, err)
+		}
+		var c Case
+		if err := yaml.Unmarshal(bs, &c); err != nil {
+			return nil, fmt.Errorf("tester: parsing %s: %w", path, err)
+		}
+		if c.Name == "" {
+			c.Name = filepath.Base(path)
+		}
+		c.path = path
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// Run evaluates every case and returns its Result. It does not stop at the first failure.
+func Run(ctx context.Context, cases []Case) ([]Result, error) {
+	results := make([]Result, 0, len(cases))
+	for _, c := range cases {
+		r, err := RunCase(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("tester: running case %q: %w", c.Name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// RunCase evaluates a single Case against the Rego produced for its Policy and checks the result
+// against its Expectation (and, if set, its golden file).
+func RunCase(ctx context.Context, c Case) (Result, error) {
+	if c.Golden != "" {
+		if err := checkGolden(c); err != nil {
+			return Result{Case: c, Pass: false, Failure: err.Error()}, nil
+		}
+	}
+
+	e, err := evaluator.NewPolicyEvaluator(ctx, evaluator.NewStore(), &c.Policy)
+	if err != nil {
+		return Result{}, fmt.Errorf("generating policy: %w", err)
+	}
+
+	output, err := e.Evaluate(ctx, &c.Input)
+	if err != nil {
+		return Result{}, fmt.Errorf("evaluating policy: %w", err)
+	}
+
+	if failure := c.Expect.diff(output); failure != "" {
+		return Result{Case: c, Pass: false, Failure: failure, Output: output}, nil
+	}
+	return Result{Case: c, Pass: true, Output: output}, nil
+}
+
+// diff compares output against the Expectation and returns a human-readable description of the
+// first mismatch, or "" if output satisfies the expectation.
+func (expect Expectation) diff(output *evaluator.PolicyOutput) string {
+	if output.Allow != expect.Allow {
+		return fmt.Sprintf("expected allow=%v, got allow=%v", expect.Allow, output.Allow)
+	}
+
+	got := make(map[string]bool, len(output.Denials))
+	for _, d := range output.Denials {
+		got[d.Code] = true
+	}
+	for _, code := range expect.Deny {
+		if !got[code] {
+			return fmt.Sprintf("expected deny reason %q, but it was not produced", code)
+		}
+		delete(got, code)
+	}
+	for code := range got {
+		return fmt.Sprintf("unexpected deny reason %q", code)
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package tester
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+// Trace re-evaluates a case's generated policy.rego with OPA's evaluation tracer enabled and
+// returns a human-readable trace, for diagnosing why a case produced an unexpected result. It
+// evaluates the PPL-generated module in isolation, without SubPolicies Rego or databroker-backed
+// data, since those require a live Store.
+func Trace(ctx context.Context, c Case) (string, error) {
+	mod, err := DefaultGenerator.Generate(c.Policy.ToPPL())
+	if err != nil {
+		return "", fmt.Errorf("tester: generating rego: %w", err)
+	}
+
+	tracer := topdown.NewBufferTracer()
+	r := rego.New(
+		rego.ParsedModule(mod),
+		rego.Query("result = data.pomerium.policy"),
+		rego.Tracer(tracer),
+	)
+
+	q, err := r.PrepareForEval(ctx)
+	if err != nil {
+		return "", fmt.Errorf("tester: preparing query: %w", err)
+	}
+
+	if _, err := q.Eval(ctx, rego.EvalInput(c.Input)); err != nil {
+		return "", fmt.Errorf("tester: evaluating: %w", err)
+	}
+
+	var buf bytes.Buffer
+	topdown.PrettyTrace(&buf, *tracer)
+	return buf.String(), nil
+}